@@ -2,18 +2,22 @@ package croc
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
 	"io"
+	"mime/multipart"
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
 
 	"golang.org/x/net/publicsuffix"
+	"golang.org/x/oauth2"
 )
 
-type Request *http.Request
-type Response *http.Response
-
 const (
 	GET     = "GET"
 	POST    = "POST"
@@ -40,205 +44,513 @@ func emptyCookieJar() http.CookieJar {
 	return cookieJar
 }
 
-type CrocClient struct {
-	url       string
-	method    string
-	headers   http.Header
-	client    *http.Client
-	transport *http.Transport
-	cookies   []*http.Cookie
-	basicAuth BasicAuth
-	rawBody   []byte
-	proxy     Proxy
-	err       error
-	//response handling
-	lastRequest    Request
-	lastResponse   Response
+// Client holds everything that's safe and worth sharing across many requests
+// and goroutines: the cookie jar, the pooling *http.Transport, default headers
+// and the middleware chain. Get a *Request from it (via Get(), Post(), ...) to
+// build and send an individual call.
+type Client struct {
+	httpClient     *http.Client
+	transport      *http.Transport
+	middlewares    []func(http.RoundTripper) http.RoundTripper
+	defaultHeaders http.Header
+	// tokenSource, once set, is wrapped in oauth2.ReuseTokenSource exactly once
+	// and shared by every Request built from this Client, so concurrent
+	// requests reuse the same cached token instead of each refreshing it
+	tokenSource oauth2.TokenSource
+}
+
+func New() *Client {
+	return &Client{
+		httpClient:     &http.Client{Jar: emptyCookieJar()},
+		transport:      &http.Transport{},
+		middlewares:    nil,
+		defaultHeaders: http.Header{},
+	}
+}
+
+// function DisableKeepAlives() controls HTTP keep-alive for every request made
+// through this client. Keep-alives are enabled by default.
+func (c *Client) DisableKeepAlives(v bool) *Client {
+	c.transport.DisableKeepAlives = v
+	return c
+}
+
+// function Use() appends a middleware that wraps the http.RoundTripper used for
+// every request, so callers can stack auth, logging, metrics, tracing, etc.
+// without forking the library. Middlewares run in the order they were added,
+// the first one registered being the outermost.
+func (c *Client) Use(mw func(http.RoundTripper) http.RoundTripper) *Client {
+	c.middlewares = append(c.middlewares, mw)
+	return c
+}
+
+// function Transport() replaces the underlying http.Transport used to build
+// the RoundTripper chain
+func (c *Client) Transport(t *http.Transport) *Client {
+	c.transport = t
+	return c
+}
+
+// function SetDefaultHeader() sets a header sent with every request built from
+// this client, unless the individual Request overrides it with SetHeader()
+func (c *Client) SetDefaultHeader(key, value string) *Client {
+	c.defaultHeaders.Set(key, value)
+	return c
+}
+
+// function SetTokenSource() installs an oauth2.TokenSource used to obtain and
+// automatically refresh the Authorization header for every Request built from
+// this Client. It is wrapped in oauth2.ReuseTokenSource exactly once here, so
+// the cached token - and any in-flight refresh - is shared across every
+// request, even when they run concurrently.
+func (c *Client) SetTokenSource(ts oauth2.TokenSource) *Client {
+	c.tokenSource = oauth2.ReuseTokenSource(nil, ts)
+	return c
+}
+
+// function RoundTripper() builds and returns the final http.RoundTripper
+// (the client's transport wrapped by every middleware registered via Use()),
+// so Do() callers bringing their own request can reuse the same chain
+func (c *Client) RoundTripper() http.RoundTripper {
+	var rt http.RoundTripper = c.transport
+	for i := len(c.middlewares) - 1; i >= 0; i-- {
+		rt = c.middlewares[i](rt)
+	}
+	return rt
+}
+
+// function Do() just does a provided request and returns the response object
+// with response-body bytes and an error
+// NOTE: Do() does not go through a Request builder, it does the minimal needed job
+// so you can call:
+//
+// client := croc.New()
+// resp, body, err := client.Do(myPremadeRequest)
+func (c *Client) Do(req *http.Request) (*http.Response, []byte, error) {
+	httpClient := &http.Client{
+		Transport: c.RoundTripper(),
+		Jar:       c.httpClient.Jar,
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	// if could not read body, but request was succesfully made - return response, nil body and an error
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp, nil, err
+	}
+	return resp, body, err
+}
+
+func (c *Client) newRequest(method, targetUrl string) *Request {
+	headers := http.Header{}
+	for key, values := range c.defaultHeaders {
+		headers[key] = append([]string(nil), values...)
+	}
+	return &Request{
+		client:      c,
+		method:      method,
+		url:         targetUrl,
+		headers:     headers,
+		cookies:     make([]*http.Cookie, 0),
+		rawBody:     make([]byte, 0),
+		tokenSource: c.tokenSource,
+	}
+}
+
+func (c *Client) Get(targetUrl string) *Request {
+	return c.newRequest(GET, targetUrl)
+}
+
+func (c *Client) Post(targetUrl string) *Request {
+	return c.newRequest(POST, targetUrl)
+}
+
+func (c *Client) Put(targetUrl string) *Request {
+	return c.newRequest(PUT, targetUrl)
+}
+
+func (c *Client) Delete(targetUrl string) *Request {
+	return c.newRequest(DELETE, targetUrl)
+}
+
+func (c *Client) Head(targetUrl string) *Request {
+	return c.newRequest(HEAD, targetUrl)
+}
+
+func (c *Client) Patch(targetUrl string) *Request {
+	return c.newRequest(PATCH, targetUrl)
+}
+
+func (c *Client) Options(targetUrl string) *Request {
+	return c.newRequest(OPTIONS, targetUrl)
+}
+
+// Request is a per-call builder returned by Client.Get()/Post()/etc. It holds
+// url, method, headers, body and the last response for a single request, so
+// it is not itself safe to share across goroutines - build a new one per call
+// from a shared *Client instead.
+type Request struct {
+	client  *Client
+	url     string
+	method  string
+	headers http.Header
+	cookies []*http.Cookie
+	proxy   Proxy
+	err     error
+	// auth, checked in the order basicAuth, bearerToken, tokenSource, authProvider
+	// and applied at request-build time
+	basicAuth    BasicAuth
+	bearerToken  string
+	tokenSource  oauth2.TokenSource
+	authProvider func(*http.Request) error
+	rawBody      []byte
+	// payloadReader, when set, is used as the request body instead of rawBody
+	// so large or unbounded bodies don't have to be buffered in memory
+	payloadReader io.Reader
+	payloadLength int64
+	// maxRespBodyBytes bounds how much of the response body End() will buffer
+	// into rawRespBody; 0 means unbounded
+	maxRespBodyBytes int64
+	// timeout, when non-zero, is applied to the http.Client used for this request
+	timeout time.Duration
+	// followRedirect controls whether redirects are followed; nil means use
+	// the http.Client default behaviour
+	followRedirect *bool
+	// retry policy: retryCount is the number of additional attempts after the first,
+	// retryBackoff is the base delay between attempts, and retryOn decides whether
+	// a given response/error should be retried
+	retryCount   int
+	retryBackoff time.Duration
+	retryOn      func(*http.Response, error) bool
+	// response handling
+	lastRequest    *http.Request
+	lastResponse   *http.Response
 	respStatusCode int
 	respHeaders    http.Header
 	rawRespBody    []byte
 	contentLength  int64
 }
 
-func New() *CrocClient {
-	cookieJar := emptyCookieJar()
-	cc := &CrocClient{
-		url:            "",
-		method:         "",
-		headers:        http.Header{},
-		client:         &http.Client{Jar: cookieJar},
-		transport:      &http.Transport{DisableKeepAlives: true},
-		cookies:        make([]*http.Cookie, 0),
-		basicAuth:      BasicAuth{},
-		rawBody:        make([]byte, 0),
-		proxy:          nil,
-		err:            nil,
-		lastRequest:    nil,
-		lastResponse:   nil,
-		respStatusCode: 0,
-		respHeaders:    http.Header{},
-		rawRespBody:    make([]byte, 0),
-		contentLength:  0,
-	}
-	return cc
-}
-
-// function Error() returns THE FIRST error that occured during client calls
-func (cc *CrocClient) Error() error {
-	return cc.err
-}
-
-// function ClearRequestData clears internal fields
-// such as url, method, headers, basicAuth and rawBody
-// NOTE: ClearRequestData() not clear cookies and proxy
-// to clear cookies/proxy use ClearCookies() and ClearProxy()
-func (cc *CrocClient) ClearRequestData() *CrocClient {
-	cc.url = ""
-	cc.method = ""
-	cc.headers = http.Header{}
-	cc.basicAuth = BasicAuth{}
-	cc.rawBody = make([]byte, 0)
-	return cc
-}
-
-// function ClearCookies() clears all of the cookies to be used with next request
-// it does not clear http.Client's cookieJar
-func (cc *CrocClient) ClearCookies() *CrocClient {
-	cc.cookies = make([]*http.Cookie, 0)
-	return cc
-}
-
-// function ClearProxy() clears a proxy to be used with next request
-func (cc *CrocClient) ClearProxy() *CrocClient {
-	cc.proxy = nil
-	return cc
-}
-
-func (cc *CrocClient) Get(targetUrl string) *CrocClient {
-	cc.ClearRequestData()
-	cc.method = GET
-	cc.url = targetUrl
-	cc.err = nil
-	return cc
-}
-
-func (cc *CrocClient) Post(targetUrl string) *CrocClient {
-	cc.ClearRequestData()
-	cc.method = POST
-	cc.url = targetUrl
-	cc.err = nil
-	return cc
-}
-
-func (cc *CrocClient) Put(targetUrl string) *CrocClient {
-	cc.ClearRequestData()
-	cc.method = PUT
-	cc.url = targetUrl
-	cc.err = nil
-	return cc
-}
-
-func (cc *CrocClient) Delete(targetUrl string) *CrocClient {
-	cc.ClearRequestData()
-	cc.method = DELETE
-	cc.url = targetUrl
-	cc.err = nil
-	return cc
-}
-
-func (cc *CrocClient) Head(targetUrl string) *CrocClient {
-	cc.ClearRequestData()
-	cc.method = HEAD
-	cc.url = targetUrl
-	cc.err = nil
-	return cc
-}
-
-func (cc *CrocClient) Patch(targetUrl string) *CrocClient {
-	cc.ClearRequestData()
-	cc.method = PATCH
-	cc.url = targetUrl
-	cc.err = nil
-	return cc
-}
-
-func (cc *CrocClient) Options(targetUrl string) *CrocClient {
-	cc.ClearRequestData()
-	cc.method = OPTIONS
-	cc.url = targetUrl
-	cc.err = nil
-	return cc
+// function Error() returns the most recent error that occured while building or sending the request;
+// a later error replaces an earlier one
+func (r *Request) Error() error {
+	return r.err
 }
 
 // function AddCookies() adds a cookies to a current request
-func (cc *CrocClient) AddCookies(cks []*http.Cookie) *CrocClient {
-	cc.cookies = append(cc.cookies, cks...)
-	return cc
+func (r *Request) AddCookies(cks []*http.Cookie) *Request {
+	r.cookies = append(r.cookies, cks...)
+	return r
 }
 
 // function SetHeader() sets header fields with single values
 // it overwrites any existing header values corresponding to the same key
-func (cc *CrocClient) SetHeader(key, value string) *CrocClient {
-	cc.headers.Set(key, value)
-	return cc
+func (r *Request) SetHeader(key, value string) *Request {
+	r.headers.Set(key, value)
+	return r
 }
 
 // function AppendHeader() sets header fields with multiple values
 // it does not overwrite any existing values, but instead appends to them
-func (cc *CrocClient) AppendHeader(key, value string) *CrocClient {
-	cc.headers.Add(key, value)
-	return cc
+func (r *Request) AppendHeader(key, value string) *Request {
+	r.headers.Add(key, value)
+	return r
 }
 
 // sets basic auth to use with a request
-func (cc *CrocClient) SetBasicAuth(username, password string) *CrocClient {
-	cc.basicAuth = BasicAuth{Username: username, Password: password}
-	return cc
+func (r *Request) SetBasicAuth(username, password string) *Request {
+	r.basicAuth = BasicAuth{Username: username, Password: password}
+	return r
+}
+
+// function SetBearerToken() sets a static bearer token to send as the
+// Authorization header of the request
+func (r *Request) SetBearerToken(token string) *Request {
+	r.bearerToken = token
+	return r
+}
+
+// function SetAuthProvider() installs a callback that can mutate the built
+// request (e.g. set a custom Authorization header) right before it's sent
+func (r *Request) SetAuthProvider(fn func(*http.Request) error) *Request {
+	r.authProvider = fn
+	return r
 }
 
-// function Proxy() is used to set a proxy to use with a request
-func (cc *CrocClient) Proxy(proxyUrl string) *CrocClient {
+// function Proxy() is used to set a proxy to use with this request
+func (r *Request) Proxy(proxyUrl string) *Request {
 	parsedUrl, err := url.Parse(proxyUrl)
 	if err != nil {
-		cc.err = err
-		return cc
+		r.err = err
+		return r
 	}
-	cc.proxy = http.ProxyURL(parsedUrl)
-	return cc
+	r.proxy = http.ProxyURL(parsedUrl)
+	return r
 }
 
 // function Payload() is used to add marshaled body to the request
-func (cc *CrocClient) Payload(data []byte) *CrocClient {
-	cc.rawBody = data
-	return cc
+func (r *Request) Payload(data []byte) *Request {
+	r.closePayloadReader()
+	r.rawBody = data
+	r.payloadReader = nil
+	r.payloadLength = 0
+	return r
+}
+
+// function PayloadReader() sets the request body to an arbitrary io.Reader
+// instead of buffering it into rawBody, so uploads of unknown or large size
+// don't have to be held in memory all at once.
+// contentLength should be the total number of bytes r will yield, or -1 if unknown.
+func (r *Request) PayloadReader(body io.Reader, contentLength int64) *Request {
+	r.closePayloadReader()
+	r.payloadReader = body
+	r.payloadLength = contentLength
+	r.rawBody = make([]byte, 0)
+	return r
+}
+
+// function PayloadFile() is a convenience wrapper around PayloadReader()
+// that streams the contents of the file at path as the request body
+func (r *Request) PayloadFile(path string) *Request {
+	f, err := os.Open(path)
+	if err != nil {
+		r.err = err
+		return r
+	}
+	info, err := f.Stat()
+	if err != nil {
+		r.err = err
+		f.Close()
+		return r
+	}
+	return r.PayloadReader(f, info.Size())
+}
+
+// function MaxRespBodyBytes() bounds how many bytes of the response body End()
+// will read into memory for RawRespBody(); 0 (the default) means unbounded.
+// If the response body exceeds the limit, End() returns an error.
+func (r *Request) MaxRespBodyBytes(n int64) *Request {
+	r.maxRespBodyBytes = n
+	return r
+}
+
+// function Timeout() sets the timeout applied to this request
+func (r *Request) Timeout(d time.Duration) *Request {
+	r.timeout = d
+	return r
+}
+
+// function FollowRedirect() controls whether the request follows HTTP redirects.
+// Passing false installs a CheckRedirect that stops at the first redirect response.
+func (r *Request) FollowRedirect(follow bool) *Request {
+	r.followRedirect = &follow
+	return r
+}
+
+// function Retry() configures a retry policy: the request will be re-executed
+// up to count additional times, waiting backoff between attempts (doubling it
+// on each subsequent attempt), whenever retryOn returns true for the attempt's
+// response/error. A Retry-After header on the response replaces backoff as the
+// wait before the next attempt. A nil retryOn defaults to retrying on network errors and 5xx/429 responses.
+func (r *Request) Retry(count int, backoff time.Duration, retryOn func(*http.Response, error) bool) *Request {
+	r.retryCount = count
+	r.retryBackoff = backoff
+	r.retryOn = retryOn
+	return r
+}
+
+// defaultRetryOn is used when Retry() is called with a nil retryOn
+func defaultRetryOn(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	if resp == nil {
+		return false
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+}
+
+// retryAfterDelay returns the delay requested by a Retry-After header, if present
+func retryAfterDelay(resp *http.Response) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return time.Until(t), true
+	}
+	return 0, false
+}
+
+// function JSON() marshals v and sets it as the request body,
+// setting Content-Type to application/json
+func (r *Request) JSON(v any) *Request {
+	data, err := json.Marshal(v)
+	if err != nil {
+		r.err = err
+		return r
+	}
+	r.Payload(data)
+	r.SetHeader("Content-Type", "application/json")
+	return r
+}
+
+// function Form() encodes values as a urlencoded form and sets it as the request body,
+// setting Content-Type to application/x-www-form-urlencoded
+func (r *Request) Form(values url.Values) *Request {
+	r.Payload([]byte(values.Encode()))
+	r.SetHeader("Content-Type", "application/x-www-form-urlencoded")
+	return r
 }
 
-func (cc *CrocClient) makeRequest() (Request, error) {
-	if cc.method == "" {
+// function Multipart() builds a multipart/form-data request body by calling fn with
+// a *multipart.Writer, closing the writer and setting Content-Type automatically
+func (r *Request) Multipart(fn func(*multipart.Writer) error) *Request {
+	buf := &bytes.Buffer{}
+	w := multipart.NewWriter(buf)
+	if err := fn(w); err != nil {
+		r.err = err
+		return r
+	}
+	if err := w.Close(); err != nil {
+		r.err = err
+		return r
+	}
+	r.Payload(buf.Bytes())
+	r.SetHeader("Content-Type", w.FormDataContentType())
+	return r
+}
+
+// function AddFile() is a convenience wrapper around Multipart() for the common case
+// of uploading a single file under fieldname
+func (r *Request) AddFile(fieldname, path string) *Request {
+	return r.Multipart(func(w *multipart.Writer) error {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		part, err := w.CreateFormFile(fieldname, filepath.Base(path))
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(part, f)
+		return err
+	})
+}
+
+// function DecodeJSON() unmarshals the last response's body into v
+func (r *Request) DecodeJSON(v any) error {
+	if err := json.Unmarshal(r.rawRespBody, v); err != nil {
+		r.err = err
+		return err
+	}
+	return nil
+}
+
+// function SaveTo() writes the last response's body to the file at path
+func (r *Request) SaveTo(path string) error {
+	if err := os.WriteFile(path, r.rawRespBody, 0644); err != nil {
+		r.err = err
+		return err
+	}
+	return nil
+}
+
+// roundTripper builds the http.RoundTripper for this request: the client's
+// transport (cloned only if this request needs its own proxy, so the shared
+// transport and its connection pool are otherwise left untouched) wrapped by
+// the client's middleware chain.
+func (r *Request) roundTripper() http.RoundTripper {
+	var base *http.Transport
+	if r.proxy != nil {
+		base = r.client.transport.Clone()
+		base.Proxy = r.proxy
+	} else {
+		base = r.client.transport
+	}
+	var rt http.RoundTripper = base
+	for i := len(r.client.middlewares) - 1; i >= 0; i-- {
+		rt = r.client.middlewares[i](rt)
+	}
+	return rt
+}
+
+// closePayloadReader closes payloadReader if it was never handed off to an
+// *http.Request (and thus would otherwise never be closed), such as an
+// *os.File opened by PayloadFile() when makeRequest fails before the body
+// is attached to a request.
+func (r *Request) closePayloadReader() {
+	if c, ok := r.payloadReader.(io.Closer); ok {
+		c.Close()
+	}
+}
+
+func (r *Request) makeRequest() (*http.Request, error) {
+	if r.method == "" {
+		r.closePayloadReader()
 		return nil, errors.New("no method specified")
 	}
-	if cc.url == "" {
+	if r.url == "" {
+		r.closePayloadReader()
 		return nil, errors.New("no url specified")
 	}
-	// create a request object
-	bodyReader := bytes.NewReader(cc.rawBody)
-	req, err := http.NewRequest(cc.method, cc.url, bodyReader)
+	// create a request object, preferring a streaming payload over the
+	// buffered rawBody when one has been set
+	var bodyReader io.Reader
+	if r.payloadReader != nil {
+		bodyReader = r.payloadReader
+	} else {
+		bodyReader = bytes.NewReader(r.rawBody)
+	}
+	req, err := http.NewRequest(r.method, r.url, bodyReader)
 	if err != nil {
+		r.closePayloadReader()
 		return nil, err
 	}
+	if r.payloadReader != nil && r.payloadLength >= 0 {
+		req.ContentLength = r.payloadLength
+	}
 	// populate it with header data
-	for key, values := range cc.headers {
+	for key, values := range r.headers {
 		for _, value := range values {
 			req.Header.Add(key, value)
 		}
 	}
 	// add basic auth
 	emptyAuth := BasicAuth{}
-	if cc.basicAuth != emptyAuth {
-		req.SetBasicAuth(cc.basicAuth.Username, cc.basicAuth.Password)
+	if r.basicAuth != emptyAuth {
+		req.SetBasicAuth(r.basicAuth.Username, r.basicAuth.Password)
+	}
+	// add bearer-token / OAuth2 auth
+	if r.bearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+r.bearerToken)
+	}
+	if r.tokenSource != nil {
+		token, err := r.tokenSource.Token()
+		if err != nil {
+			req.Body.Close()
+			return nil, err
+		}
+		token.SetAuthHeader(req)
+	}
+	if r.authProvider != nil {
+		if err := r.authProvider(req); err != nil {
+			req.Body.Close()
+			return nil, err
+		}
 	}
 	// add cookies
-	for _, cookie := range cc.cookies {
+	for _, cookie := range r.cookies {
 		req.AddCookie(cookie)
 	}
 
@@ -246,115 +558,159 @@ func (cc *CrocClient) makeRequest() (Request, error) {
 }
 
 // function End() ends the call-chain and makes a request
-func (cc *CrocClient) End() error {
-	if cc.err != nil {
-		return cc.err
-	}
-	req, err := cc.makeRequest()
+func (r *Request) End() error {
+	resp, err := r.doRequest()
 	if err != nil {
-		cc.err = err
 		return err
 	}
-	cc.lastRequest = req
-	// set proxy to transport
-	cc.transport.Proxy = cc.proxy
-	// set transport
-	cc.client.Transport = cc.transport
-	// now make a request
-	resp, err := cc.client.Do(req)
+	defer resp.Body.Close()
+	bodyReader := io.Reader(resp.Body)
+	if r.maxRespBodyBytes > 0 {
+		bodyReader = io.LimitReader(resp.Body, r.maxRespBodyBytes+1)
+	}
+	body, err := io.ReadAll(bodyReader)
 	if err != nil {
-		cc.err = err
+		r.err = err
 		return err
 	}
-	cc.lastResponse = resp
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
+	if r.maxRespBodyBytes > 0 && int64(len(body)) > r.maxRespBodyBytes {
+		err := errors.New("response body exceeds MaxRespBodyBytes limit")
+		r.err = err
+		return err
+	}
+	r.rawRespBody = body
+	r.respStatusCode = resp.StatusCode
+	r.respHeaders = resp.Header
+	r.contentLength = resp.ContentLength
+	return nil
+}
+
+// function EndStream() ends the call-chain like End(), but returns the response
+// body unread so callers can stream it themselves (downloads, proxying, etc).
+// The caller is responsible for closing the returned io.ReadCloser.
+func (r *Request) EndStream() (*http.Response, io.ReadCloser, error) {
+	resp, err := r.doRequest()
+	if err != nil {
+		return nil, nil, err
+	}
+	r.respStatusCode = resp.StatusCode
+	r.respHeaders = resp.Header
+	r.contentLength = resp.ContentLength
+	return resp, resp.Body, nil
+}
+
+// function Stream() ends the call-chain and hands the response body to fn
+// without buffering it, closing the body once fn returns
+func (r *Request) Stream(fn func(io.Reader) error) error {
+	_, body, err := r.EndStream()
 	if err != nil {
-		cc.err = err
 		return err
 	}
-	cc.rawRespBody = body
-	cc.respStatusCode = resp.StatusCode
-	cc.respHeaders = resp.Header
-	cc.contentLength = resp.ContentLength
+	defer body.Close()
+	if err := fn(body); err != nil {
+		r.err = err
+		return err
+	}
 	return nil
 }
 
-// function Request() returns the last request made (even if it returned an error)
-func (cc *CrocClient) Request() Request {
-	return cc.lastRequest
+// doRequest builds and executes the request, recording lastRequest/lastResponse,
+// but leaves the response body unread. It runs against a *http.Client built
+// fresh for this call (sharing the parent Client's cookie jar and transport),
+// so concurrent requests from a shared Client never race over Timeout or
+// CheckRedirect. It applies the configured timeout, redirect policy and retry
+// policy around calls to that client's Do.
+func (r *Request) doRequest() (*http.Response, error) {
+	if r.err != nil {
+		// a builder call after PayloadReader()/PayloadFile() (e.g. a failing
+		// Proxy() or JSON()) means makeRequest() will never run, so the
+		// payload reader's fd would otherwise never be closed
+		r.closePayloadReader()
+		return nil, r.err
+	}
+	httpClient := &http.Client{
+		Transport: r.roundTripper(),
+		Jar:       r.client.httpClient.Jar,
+		Timeout:   r.timeout,
+	}
+	if r.followRedirect != nil && !*r.followRedirect {
+		httpClient.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+	}
+
+	retryOn := r.retryOn
+	if retryOn == nil {
+		retryOn = defaultRetryOn
+	}
+	// a streaming payload can only be read once, so it can't be safely re-sent
+	retriesAllowed := r.retryCount
+	if r.payloadReader != nil {
+		retriesAllowed = 0
+	}
+
+	backoff := r.retryBackoff
+	var resp *http.Response
+	var err error
+	for attempt := 0; attempt <= retriesAllowed; attempt++ {
+		var req *http.Request
+		req, err = r.makeRequest()
+		if err != nil {
+			r.err = err
+			return nil, err
+		}
+		r.lastRequest = req
+		resp, err = httpClient.Do(req)
+		r.lastResponse = resp
+		if attempt == retriesAllowed || !retryOn(resp, err) {
+			break
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+		delay := backoff
+		if d, ok := retryAfterDelay(resp); ok {
+			delay = d
+		}
+		if delay > 0 {
+			time.Sleep(delay)
+		}
+		backoff *= 2
+	}
+	if err != nil {
+		r.err = err
+		return nil, err
+	}
+	return resp, nil
 }
 
-// function Response() returns the last succesfully recieved response
-func (cc *CrocClient) Response() Response {
-	return cc.lastResponse
+// function LastRequest() returns the last request made (even if it returned an error)
+func (r *Request) LastRequest() *http.Request {
+	return r.lastRequest
+}
+
+// function LastResponse() returns the response of the last attempt made;
+// nil if that attempt failed with an error
+func (r *Request) LastResponse() *http.Response {
+	return r.lastResponse
 }
 
 // function RespStatus() last responses status code
-func (cc *CrocClient) RespStatus() int {
-	return cc.respStatusCode
+func (r *Request) RespStatus() int {
+	return r.respStatusCode
 }
 
 // function RespHeaders() returns last responses headers
-func (cc *CrocClient) RespHeaders() http.Header {
-	return cc.respHeaders
+func (r *Request) RespHeaders() http.Header {
+	return r.respHeaders
 }
 
 // function RespLength() returns last responses content length
-func (cc *CrocClient) RespLength() int64 {
-	return cc.contentLength
+func (r *Request) RespLength() int64 {
+	return r.contentLength
 }
 
 // function RawRespBody() returns last responses body as raw bytes
-func (cc *CrocClient) RawRespBody() []byte {
-	return cc.rawRespBody
-}
-
-// function Do() just does a provided request WITH A SET PROXY
-// and returns the response object with response-body bytes and an error
-// NOTE: Do() does not save the request and response fields and objects, it does the minimal needed job
-// so you can call:
-//
-// client := croc.New()
-// client.Proxy("1.2.3.4:1337")
-// resp, body, err := client.Do(myPremadeRequest)
-func (cc *CrocClient) Do(req Request) (Response, []byte, error) {
-	// set proxy to transport
-	cc.transport.Proxy = cc.proxy
-	// set transport
-	cc.client.Transport = cc.transport
-	// now make a request
-	resp, err := cc.client.Do(req)
-	if err != nil {
-		return nil, nil, err
-	}
-	// if could not read body, but request was succesfully made - return request, nil body and an error
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return resp, nil, err
-	}
-	return resp, body, err
-}
-
-/*
-type CrocClient struct {
-	url       string
-	method    string
-	headers   http.Header
-	client    *http.Client
-	transport *http.Transport
-	cookies   []*http.Cookie
-	basicAuth BasicAuth
-	rawBody   []byte
-	proxy     Proxy
-	err       error
-	//response handling
-	lastRequest    Request
-	lastResponse   Response
-	respStatusCode int
-	respHeaders    http.Header
-	rawRespBody    []byte
-	contentLength  int64
+func (r *Request) RawRespBody() []byte {
+	return r.rawRespBody
 }
-*/
@@ -0,0 +1,490 @@
+package croc_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/timohahaa/croc"
+	"golang.org/x/oauth2"
+)
+
+// trackedCloser wraps a reader so tests can assert whether Close() was called
+type trackedCloser struct {
+	io.Reader
+	closed bool
+}
+
+func (t *trackedCloser) Close() error {
+	t.closed = true
+	return nil
+}
+
+func TestRetryHonorsRetryAfterHeader(t *testing.T) {
+	var attempts int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if atomic.AddInt32(&attempts, 1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	client := croc.New()
+	req := client.Get(srv.URL).Retry(1, 10*time.Millisecond, nil)
+
+	start := time.Now()
+	if err := req.End(); err != nil {
+		t.Fatalf("End() returned error: %v", err)
+	}
+	elapsed := time.Since(start)
+
+	if atomic.LoadInt32(&attempts) != 2 {
+		t.Fatalf("expected 2 attempts, got %d", attempts)
+	}
+	if elapsed < time.Second {
+		t.Fatalf("expected the retry to wait out the Retry-After header (>= 1s), waited %s", elapsed)
+	}
+	if req.RespStatus() != http.StatusOK {
+		t.Fatalf("expected final status 200, got %d", req.RespStatus())
+	}
+	if string(req.RawRespBody()) != "ok" {
+		t.Fatalf("expected body %q, got %q", "ok", req.RawRespBody())
+	}
+	if req.LastResponse() == nil || req.LastResponse().StatusCode != http.StatusOK {
+		t.Fatalf("expected LastResponse() to reflect the final (successful) attempt")
+	}
+}
+
+func TestRetryDefaultBackoffDoublesAndGivesUp(t *testing.T) {
+	var attempts int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	client := croc.New()
+	req := client.Get(srv.URL).Retry(2, 10*time.Millisecond, nil)
+
+	start := time.Now()
+	if err := req.End(); err != nil {
+		t.Fatalf("End() returned error: %v", err)
+	}
+	elapsed := time.Since(start)
+
+	// 1 initial attempt + 2 retries
+	if atomic.LoadInt32(&attempts) != 3 {
+		t.Fatalf("expected 3 attempts, got %d", attempts)
+	}
+	// backoff doubles: 10ms then 20ms
+	if elapsed < 30*time.Millisecond {
+		t.Fatalf("expected backoff to double across retries, only waited %s", elapsed)
+	}
+	if req.RespStatus() != http.StatusInternalServerError {
+		t.Fatalf("expected final status 500, got %d", req.RespStatus())
+	}
+}
+
+func TestPayloadReaderClosedWhenBuilderErrorShortCircuitsSend(t *testing.T) {
+	client := croc.New()
+	tc := &trackedCloser{Reader: bytes.NewReader([]byte("payload"))}
+
+	req := client.Post("http://example.invalid").
+		PayloadReader(tc, 7).
+		Proxy("://bad-proxy-url")
+
+	if err := req.End(); err == nil {
+		t.Fatal("expected End() to fail because Proxy() recorded a parse error")
+	}
+	if !tc.closed {
+		t.Fatal("expected the payload reader to be closed when a prior builder error short-circuits doRequest()")
+	}
+}
+
+func TestPayloadReaderClosesPreviousReaderWhenReplaced(t *testing.T) {
+	client := croc.New()
+	first := &trackedCloser{Reader: bytes.NewReader([]byte("first"))}
+	second := &trackedCloser{Reader: bytes.NewReader([]byte("second"))}
+
+	client.Post("http://example.invalid").
+		PayloadReader(first, 5).
+		PayloadReader(second, 6)
+
+	if !first.closed {
+		t.Fatal("expected the first payload reader to be closed once replaced by a second PayloadReader() call")
+	}
+	if second.closed {
+		t.Fatal("the second payload reader should not be closed until the request is actually sent")
+	}
+}
+
+func TestStreamAndEndStreamDoNotBufferBody(t *testing.T) {
+	const want = "streamed response body"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(want))
+	}))
+	defer srv.Close()
+
+	client := croc.New()
+
+	var got string
+	err := client.Get(srv.URL).Stream(func(r io.Reader) error {
+		data, err := io.ReadAll(r)
+		got = string(data)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Stream() returned error: %v", err)
+	}
+	if got != want {
+		t.Fatalf("expected streamed body %q, got %q", want, got)
+	}
+
+	resp, body, err := client.Get(srv.URL).EndStream()
+	if err != nil {
+		t.Fatalf("EndStream() returned error: %v", err)
+	}
+	defer body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatalf("failed reading streamed body: %v", err)
+	}
+	if string(data) != want {
+		t.Fatalf("expected streamed body %q, got %q", want, data)
+	}
+}
+
+func TestMaxRespBodyBytesRejectsOversizedBody(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("this response is way too long"))
+	}))
+	defer srv.Close()
+
+	client := croc.New()
+	req := client.Get(srv.URL).MaxRespBodyBytes(4)
+
+	if err := req.End(); err == nil {
+		t.Fatal("expected End() to fail when the response exceeds MaxRespBodyBytes")
+	}
+}
+
+func TestJSONSendsBodyAndContentTypeAndDecodesResponse(t *testing.T) {
+	type payload struct {
+		Name string `json:"name"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if ct := req.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("expected Content-Type application/json, got %q", ct)
+		}
+		var got payload
+		if err := json.NewDecoder(req.Body).Decode(&got); err != nil {
+			t.Errorf("failed decoding request body: %v", err)
+		}
+		if got.Name != "gopher" {
+			t.Errorf("expected request body name %q, got %q", "gopher", got.Name)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(payload{Name: "reply"})
+	}))
+	defer srv.Close()
+
+	client := croc.New()
+	req := client.Post(srv.URL).JSON(payload{Name: "gopher"})
+	if err := req.End(); err != nil {
+		t.Fatalf("End() returned error: %v", err)
+	}
+
+	var resp payload
+	if err := req.DecodeJSON(&resp); err != nil {
+		t.Fatalf("DecodeJSON() returned error: %v", err)
+	}
+	if resp.Name != "reply" {
+		t.Fatalf("expected decoded response name %q, got %q", "reply", resp.Name)
+	}
+}
+
+func TestFormEncodesValuesAndSetsContentType(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if ct := req.Header.Get("Content-Type"); ct != "application/x-www-form-urlencoded" {
+			t.Errorf("expected form Content-Type, got %q", ct)
+		}
+		if err := req.ParseForm(); err != nil {
+			t.Errorf("failed parsing form body: %v", err)
+		}
+		if got := req.Form.Get("key"); got != "value" {
+			t.Errorf("expected form field key=%q, got %q", "value", got)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	client := croc.New()
+	values := url.Values{}
+	values.Set("key", "value")
+	if err := client.Post(srv.URL).Form(values).End(); err != nil {
+		t.Fatalf("End() returned error: %v", err)
+	}
+}
+
+func TestAddFileUploadsMultipartFormWithFieldAndFilename(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "upload.txt")
+	if err := os.WriteFile(path, []byte("file contents"), 0o644); err != nil {
+		t.Fatalf("failed writing temp file: %v", err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if err := req.ParseMultipartForm(1 << 20); err != nil {
+			t.Errorf("failed parsing multipart form: %v", err)
+			return
+		}
+		file, header, err := req.FormFile("upload")
+		if err != nil {
+			t.Errorf("failed reading form file: %v", err)
+			return
+		}
+		defer file.Close()
+		if header.Filename != "upload.txt" {
+			t.Errorf("expected filename %q, got %q", "upload.txt", header.Filename)
+		}
+		data, err := io.ReadAll(file)
+		if err != nil {
+			t.Errorf("failed reading uploaded file: %v", err)
+			return
+		}
+		if string(data) != "file contents" {
+			t.Errorf("expected uploaded contents %q, got %q", "file contents", data)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	client := croc.New()
+	if err := client.Post(srv.URL).AddFile("upload", path).End(); err != nil {
+		t.Fatalf("End() returned error: %v", err)
+	}
+}
+
+func TestSaveToWritesResponseBodyToFile(t *testing.T) {
+	const want = "saved response body"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(want))
+	}))
+	defer srv.Close()
+
+	client := croc.New()
+	req := client.Get(srv.URL)
+	if err := req.End(); err != nil {
+		t.Fatalf("End() returned error: %v", err)
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out.txt")
+	if err := req.SaveTo(path); err != nil {
+		t.Fatalf("SaveTo() returned error: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed reading saved file: %v", err)
+	}
+	if string(data) != want {
+		t.Fatalf("expected saved file contents %q, got %q", want, data)
+	}
+}
+
+func TestUseMiddlewareWrapsRoundTripperForEveryRequest(t *testing.T) {
+	var gotHeader string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		gotHeader = req.Header.Get("X-Middleware")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	client := croc.New().Use(func(next http.RoundTripper) http.RoundTripper {
+		return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			req.Header.Set("X-Middleware", "applied")
+			return next.RoundTrip(req)
+		})
+	})
+
+	if err := client.Get(srv.URL).End(); err != nil {
+		t.Fatalf("End() returned error: %v", err)
+	}
+	if gotHeader != "applied" {
+		t.Fatalf("expected middleware to set X-Middleware header, got %q", gotHeader)
+	}
+
+	// Do() bypasses the Request builder but must still go through the same
+	// middleware chain via RoundTripper()
+	gotHeader = ""
+	httpReq, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+	if err != nil {
+		t.Fatalf("failed building request: %v", err)
+	}
+	if _, _, err := client.Do(httpReq); err != nil {
+		t.Fatalf("Do() returned error: %v", err)
+	}
+	if gotHeader != "applied" {
+		t.Fatalf("expected middleware to apply to Do() requests too, got %q", gotHeader)
+	}
+}
+
+// roundTripperFunc lets a plain function satisfy http.RoundTripper
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestSetBearerTokenSendsAuthorizationHeader(t *testing.T) {
+	var gotAuth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		gotAuth = req.Header.Get("Authorization")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	client := croc.New()
+	if err := client.Get(srv.URL).SetBearerToken("mytoken").End(); err != nil {
+		t.Fatalf("End() returned error: %v", err)
+	}
+	if want := "Bearer mytoken"; gotAuth != want {
+		t.Fatalf("expected Authorization header %q, got %q", want, gotAuth)
+	}
+}
+
+func TestSetAuthProviderMutatesRequestBeforeSend(t *testing.T) {
+	var gotAuth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		gotAuth = req.Header.Get("Authorization")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	client := croc.New()
+	err := client.Get(srv.URL).SetAuthProvider(func(req *http.Request) error {
+		req.Header.Set("Authorization", "Custom abc123")
+		return nil
+	}).End()
+	if err != nil {
+		t.Fatalf("End() returned error: %v", err)
+	}
+	if want := "Custom abc123"; gotAuth != want {
+		t.Fatalf("expected Authorization header %q, got %q", want, gotAuth)
+	}
+}
+
+// countingTokenSource issues a fresh token each call and counts how many times
+// Token() was invoked, so tests can assert a Client's oauth2.TokenSource is
+// cached and shared rather than re-wrapped (and re-fetched) per request.
+type countingTokenSource struct {
+	calls int32
+}
+
+func (c *countingTokenSource) Token() (*oauth2.Token, error) {
+	atomic.AddInt32(&c.calls, 1)
+	return &oauth2.Token{AccessToken: "cached-token"}, nil
+}
+
+func TestSetTokenSourceSendsBearerHeaderAndIsCachedAcrossRequests(t *testing.T) {
+	var gotAuth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		gotAuth = req.Header.Get("Authorization")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	ts := &countingTokenSource{}
+	client := croc.New().SetTokenSource(ts)
+
+	for i := 0; i < 3; i++ {
+		if err := client.Get(srv.URL).End(); err != nil {
+			t.Fatalf("End() returned error: %v", err)
+		}
+	}
+
+	if want := "Bearer cached-token"; gotAuth != want {
+		t.Fatalf("expected Authorization header %q, got %q", want, gotAuth)
+	}
+	if calls := atomic.LoadInt32(&ts.calls); calls != 1 {
+		t.Fatalf("expected the token source to be wrapped in a cache shared across requests (1 underlying call), got %d calls", calls)
+	}
+}
+
+func TestSharedClientHandlesConcurrentRequests(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(req.URL.Query().Get("n")))
+	}))
+	defer srv.Close()
+
+	client := croc.New()
+	const n = 50
+
+	var wg sync.WaitGroup
+	errs := make([]error, n)
+	bodies := make([]string, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			req := client.Get(srv.URL + "?n=" + strconv.Itoa(i))
+			if err := req.End(); err != nil {
+				errs[i] = err
+				return
+			}
+			bodies[i] = string(req.RawRespBody())
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < n; i++ {
+		if errs[i] != nil {
+			t.Fatalf("request %d returned error: %v", i, errs[i])
+		}
+		if want := strconv.Itoa(i); bodies[i] != want {
+			t.Fatalf("request %d: expected body %q, got %q", i, want, bodies[i])
+		}
+	}
+}
+
+func TestRetryOnRejectsStreamingPayload(t *testing.T) {
+	var attempts int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	client := croc.New()
+	payload := []byte("payload")
+	req := client.Post(srv.URL).
+		PayloadReader(bytes.NewReader(payload), int64(len(payload))).
+		Retry(3, time.Millisecond, func(resp *http.Response, err error) bool {
+			return resp != nil && resp.StatusCode >= 500
+		})
+
+	if err := req.End(); err != nil {
+		t.Fatalf("End() returned error: %v", err)
+	}
+	if atomic.LoadInt32(&attempts) != 1 {
+		t.Fatalf("expected a streaming payload to disable retries, got %d attempts", attempts)
+	}
+}